@@ -1,7 +1,12 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -23,7 +28,11 @@ func CheckPasswordHash(hash, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-// MakeJWT creates and returns a signed JWT for the given user ID.
+// MakeJWT creates and returns an HS256-signed JWT for the given user ID.
+//
+// Deprecated: the server signs access tokens with MakeJWTWithKeySet so they
+// validate against the published JWKS document. MakeJWT is kept only for
+// symmetric-secret use cases outside the main request flow.
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
 	// Define the claims
 	claims := jwt.RegisteredClaims{
@@ -45,7 +54,11 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return signedToken, nil
 }
 
-// ValidateJWT validates a JWT and extracts the user ID from its claims.
+// ValidateJWT validates an HS256 JWT and extracts the user ID from its claims.
+//
+// Deprecated: the server validates access tokens with ValidateJWTWithKeySet.
+// ValidateJWT is kept only for symmetric-secret use cases outside the main
+// request flow.
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 	// Parse the token and validate its signature
 	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
@@ -73,3 +86,122 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 
 	return userID, nil
 }
+
+// signingMethodFor returns the jwt-go signing method matching alg.
+func signingMethodFor(alg KeyAlgorithm) jwt.SigningMethod {
+	if alg == AlgES256 {
+		return jwt.SigningMethodES256
+	}
+	return jwt.SigningMethodRS256
+}
+
+// MakeJWTWithKeySet mints an RS256 or ES256 JWT for userID (matching
+// whichever algorithm keySet's current signing key uses), tagging the token
+// header with that key's kid so downstream services can validate it via
+// JWKS without sharing a secret.
+func MakeJWTWithKeySet(userID uuid.UUID, keySet *KeySet, expiresIn time.Duration) (string, error) {
+	key, err := keySet.signingKeyFor()
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		Subject:   userID.String(),
+	}
+
+	token := jwt.NewWithClaims(signingMethodFor(key.alg), claims)
+	token.Header["kid"] = key.kid
+
+	return token.SignedString(key.privateKey)
+}
+
+// ValidateJWTWithKeySet validates an RS256 or ES256 JWT against keySet,
+// selecting the public key named by the token's "kid" header and requiring
+// its signing method to match the algorithm that key was generated for, and
+// extracts the user ID.
+func ValidateJWTWithKeySet(tokenString string, keySet *KeySet) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("token missing kid header")
+		}
+		publicKey, alg, err := keySet.verifyingKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != signingMethodFor(alg).Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return publicKey, nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok || !token.Valid {
+		return uuid.Nil, errors.New("invalid token")
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, errors.New("invalid user ID in token")
+	}
+
+	return userID, nil
+}
+
+// MakeRefreshToken generates a 256-bit random token, hex-encoded, suitable
+// for use as an opaque refresh token.
+func MakeRefreshToken() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// GetBearerToken extracts the token from a "Bearer <token>" Authorization
+// header.
+func GetBearerToken(headers http.Header) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", errors.New("no authorization header included")
+	}
+
+	splitAuth := strings.Split(authHeader, " ")
+	if len(splitAuth) != 2 || splitAuth[0] != "Bearer" {
+		return "", errors.New("malformed authorization header")
+	}
+
+	return splitAuth[1], nil
+}
+
+// GetAPIKey extracts the key from an "ApiKey <key>" Authorization header.
+func GetAPIKey(headers http.Header) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", errors.New("no authorization header included")
+	}
+
+	splitAuth := strings.Split(authHeader, " ")
+	if len(splitAuth) != 2 || splitAuth[0] != "ApiKey" {
+		return "", errors.New("malformed authorization header")
+	}
+
+	return splitAuth[1], nil
+}
+
+// CheckAPIKey compares an API key against the expected value in constant
+// time, so a mismatch can't be used to time-probe the expected key. An
+// empty expected key always fails closed instead of matching an empty
+// Authorization header.
+func CheckAPIKey(key, expected string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(key), []byte(expected)) == 1
+}
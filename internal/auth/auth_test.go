@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"encoding/hex"
 	"testing"
 	"time"
 
@@ -59,3 +60,28 @@ func TestInvalidJWTSignature(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "signature is invalid")
 }
+
+func TestMakeRefreshToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	assert.NoError(t, err)
+
+	// A 256-bit token hex-encodes to 64 characters
+	assert.Len(t, token, 64)
+	_, err = hex.DecodeString(token)
+	assert.NoError(t, err)
+
+	// Each call must draw fresh randomness, not repeat a token
+	other, err := MakeRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestCheckAPIKey(t *testing.T) {
+	assert.True(t, CheckAPIKey("polka-secret", "polka-secret"))
+	assert.False(t, CheckAPIKey("wrong-key", "polka-secret"))
+
+	// An unconfigured expected key must fail closed, not match an empty
+	// Authorization header.
+	assert.False(t, CheckAPIKey("", ""))
+	assert.False(t, CheckAPIKey("anything", ""))
+}
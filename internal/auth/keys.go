@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// rsaKeySize is the bit length used for generated RS256 signing keys.
+const rsaKeySize = 2048
+
+// KeyAlgorithm identifies which asymmetric algorithm a signing key uses.
+type KeyAlgorithm string
+
+const (
+	AlgRS256 KeyAlgorithm = "RS256"
+	AlgES256 KeyAlgorithm = "ES256"
+)
+
+// signingKey is a single asymmetric key pair tagged with a "kid" and the
+// algorithm it was generated for. Keys start out able to sign new tokens and
+// are demoted to verify-only once rotated out.
+type signingKey struct {
+	kid        string
+	alg        KeyAlgorithm
+	privateKey crypto.Signer
+	verifyOnly bool
+	retireAt   time.Time // once the clock passes this, no outstanding token can still reference this key
+}
+
+// KeySet holds every RS256/ES256 signing key Chirpy currently knows about,
+// keyed by kid, so that tokens can carry a kid header and validation can
+// look up the matching public key without sharing a symmetric secret.
+type KeySet struct {
+	mu      sync.RWMutex
+	dir     string
+	alg     KeyAlgorithm // algorithm used for keys this KeySet generates
+	keys    map[string]*signingKey
+	current string // kid of the key used to sign new tokens
+}
+
+// KeySetOption configures a KeySet at construction time.
+type KeySetOption func(*KeySet)
+
+// WithAlgorithm sets the algorithm used for keys this KeySet generates
+// (RS256 by default). Keys already loaded from disk keep whichever
+// algorithm they were originally generated for.
+func WithAlgorithm(alg KeyAlgorithm) KeySetOption {
+	return func(ks *KeySet) { ks.alg = alg }
+}
+
+// manifestFileName holds the keyset's rotation state (the active kid and
+// each verify-only key's retirement time) so it survives a restart instead
+// of being re-inferred from directory iteration order.
+const manifestFileName = "manifest.json"
+
+// keySetManifest is the on-disk form of a KeySet's rotation state.
+type keySetManifest struct {
+	Current string               `json:"current"`
+	Retired map[string]time.Time `json:"retired"`
+}
+
+// NewKeySet loads PEM-encoded private keys from dir (one file per kid,
+// named "<kid>.pem"), restores which key is active and which are
+// verify-only from the manifest file alongside them, and generates a fresh
+// signing key (saved to dir) if none are found. dir is created if it
+// doesn't exist. Keys generated by this KeySet use RS256 unless
+// WithAlgorithm is passed; keys loaded from disk keep whichever algorithm
+// they were originally generated for.
+func NewKeySet(dir string, opts ...KeySetOption) (*KeySet, error) {
+	ks := &KeySet{
+		dir:  dir,
+		alg:  AlgRS256,
+		keys: make(map[string]*signingKey),
+	}
+	for _, opt := range opts {
+		opt(ks)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create key dir: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read key dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+		kid := strings.TrimSuffix(entry.Name(), ".pem")
+		key, alg, err := loadPrivateKey(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("load key %s: %w", kid, err)
+		}
+		ks.keys[kid] = &signingKey{kid: kid, alg: alg, privateKey: key}
+	}
+
+	manifest, err := ks.loadManifest()
+	if err != nil {
+		return nil, fmt.Errorf("load key manifest: %w", err)
+	}
+	for kid, retireAt := range manifest.Retired {
+		if key, ok := ks.keys[kid]; ok {
+			key.verifyOnly = true
+			key.retireAt = retireAt
+		}
+	}
+	if _, ok := ks.keys[manifest.Current]; ok {
+		ks.current = manifest.Current
+	}
+
+	if ks.current == "" {
+		if _, err := ks.generate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ks, nil
+}
+
+// generate creates a new key pair in ks.alg, saves it under ks.dir, makes it
+// the active signing key, and persists the updated manifest. Callers must
+// hold ks.mu for writing.
+func (ks *KeySet) generate() (*signingKey, error) {
+	var privateKey crypto.Signer
+	var err error
+	switch ks.alg {
+	case AlgES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeySize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+
+	kid := uuid.NewString()
+	if ks.dir != "" {
+		if err := savePrivateKey(filepath.Join(ks.dir, kid+".pem"), privateKey); err != nil {
+			return nil, fmt.Errorf("save key %s: %w", kid, err)
+		}
+	}
+
+	key := &signingKey{kid: kid, alg: ks.alg, privateKey: privateKey}
+	ks.keys[kid] = key
+	ks.current = kid
+
+	if err := ks.saveManifest(); err != nil {
+		return nil, fmt.Errorf("save key manifest: %w", err)
+	}
+	return key, nil
+}
+
+// Rotate generates a new signing key, demotes the previous signing key to
+// verify-only, and schedules it to be dropped once maxTokenLifetime has
+// passed (the point at which no token it signed can still be valid). It
+// returns the new key's kid.
+func (ks *KeySet) Rotate(maxTokenLifetime time.Duration) (string, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if previous, ok := ks.keys[ks.current]; ok {
+		previous.verifyOnly = true
+		previous.retireAt = time.Now().Add(maxTokenLifetime)
+	}
+
+	key, err := ks.generate()
+	if err != nil {
+		return "", err
+	}
+	return key.kid, nil
+}
+
+// Prune removes verify-only keys whose retirement time has passed, deleting
+// their PEM files from disk and persisting the updated manifest.
+func (ks *KeySet) Prune() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := time.Now()
+	changed := false
+	for kid, key := range ks.keys {
+		if key.verifyOnly && now.After(key.retireAt) {
+			delete(ks.keys, kid)
+			if ks.dir != "" {
+				os.Remove(filepath.Join(ks.dir, kid+".pem"))
+			}
+			changed = true
+		}
+	}
+	if changed {
+		ks.saveManifest()
+	}
+}
+
+// loadManifest reads the keyset's rotation state from disk, returning an
+// empty manifest if none has been written yet.
+func (ks *KeySet) loadManifest() (*keySetManifest, error) {
+	manifest := &keySetManifest{Retired: make(map[string]time.Time)}
+	if ks.dir == "" {
+		return manifest, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(ks.dir, manifestFileName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Retired == nil {
+		manifest.Retired = make(map[string]time.Time)
+	}
+	return manifest, nil
+}
+
+// saveManifest writes the keyset's current rotation state to disk. Callers
+// must hold ks.mu for writing.
+func (ks *KeySet) saveManifest() error {
+	if ks.dir == "" {
+		return nil
+	}
+
+	manifest := keySetManifest{Current: ks.current, Retired: make(map[string]time.Time)}
+	for kid, key := range ks.keys {
+		if key.verifyOnly {
+			manifest.Retired[kid] = key.retireAt
+		}
+	}
+
+	data, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(ks.dir, manifestFileName), data, 0o600)
+}
+
+// signingKeyFor returns the key currently used to sign new tokens.
+func (ks *KeySet) signingKeyFor() (*signingKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, ok := ks.keys[ks.current]
+	if !ok {
+		return nil, errors.New("no active signing key")
+	}
+	return key, nil
+}
+
+// verifyingKey returns the public key registered under kid and the
+// algorithm it was generated for, whether or not it's still used to sign
+// new tokens.
+func (ks *KeySet) verifyingKey(kid string) (crypto.PublicKey, KeyAlgorithm, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown key id %q", kid)
+	}
+	return key.privateKey.Public(), key.alg, nil
+}
+
+// JWKS returns the current set of public keys as a JWKS document, as served
+// from GET /.well-known/jwks.json. RSA keys are published as kty "RSA" with
+// n/e; EC keys are published as kty "EC" with crv/x/y.
+func (ks *KeySet) JWKS() map[string]interface{} {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	keys := make([]map[string]interface{}, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		switch pub := key.privateKey.Public().(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]interface{}{
+				"kty": "RSA",
+				"kid": key.kid,
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big32(pub.E)),
+			})
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			keys = append(keys, map[string]interface{}{
+				"kty": "EC",
+				"kid": key.kid,
+				"alg": "ES256",
+				"use": "sig",
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+				"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+			})
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// big32 encodes a small exponent (typically 65537) as big-endian bytes with
+// no leading zero, as JWKS expects.
+func big32(e int) []byte {
+	b := []byte{byte(e >> 16), byte(e >> 8), byte(e)}
+	i := 0
+	for i < len(b)-1 && b[i] == 0 {
+		i++
+	}
+	return b[i:]
+}
+
+// loadPrivateKey reads a PEM-encoded PKCS#8 private key (RSA or ECDSA) and
+// returns it along with the algorithm it implies. It also accepts the
+// legacy PKCS#1 RSA format written by older versions of this keyset.
+func loadPrivateKey(path string) (crypto.Signer, KeyAlgorithm, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, "", errors.New("invalid PEM block")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, AlgRS256, nil
+		case *ecdsa.PrivateKey:
+			return key, AlgES256, nil
+		default:
+			return nil, "", fmt.Errorf("unsupported private key type %T", key)
+		}
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("parse private key: %w", err)
+	}
+	return key, AlgRS256, nil
+}
+
+// savePrivateKey writes key to path as a PEM-encoded PKCS#8 block, the
+// format both RSA and ECDSA private keys share.
+func savePrivateKey(path string, key crypto.Signer) error {
+	bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("marshal private key: %w", err)
+	}
+	block := &pem.Block{Type: "PRIVATE KEY", Bytes: bytes}
+	return os.WriteFile(path, pem.EncodeToMemory(block), 0o600)
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeAndValidateJWTWithKeySet(t *testing.T) {
+	keySet, err := NewKeySet(t.TempDir())
+	assert.NoError(t, err)
+
+	userID := uuid.New()
+	token, err := MakeJWTWithKeySet(userID, keySet, time.Minute)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	parsedUserID, err := ValidateJWTWithKeySet(token, keySet)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, parsedUserID)
+}
+
+func TestRotateRetiresPreviousKeyForVerification(t *testing.T) {
+	keySet, err := NewKeySet(t.TempDir())
+	assert.NoError(t, err)
+
+	userID := uuid.New()
+	oldToken, err := MakeJWTWithKeySet(userID, keySet, time.Minute)
+	assert.NoError(t, err)
+
+	_, err = keySet.Rotate(time.Minute)
+	assert.NoError(t, err)
+
+	// Tokens signed by the retired key must still validate until they expire
+	parsedUserID, err := ValidateJWTWithKeySet(oldToken, keySet)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, parsedUserID)
+}
+
+func TestNewKeySetRestoresRotationStateAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+
+	keySet, err := NewKeySet(dir)
+	assert.NoError(t, err)
+
+	firstKid, err := keySet.signingKeyFor()
+	assert.NoError(t, err)
+
+	secondKid, err := keySet.Rotate(time.Minute)
+	assert.NoError(t, err)
+	assert.NotEqual(t, firstKid.kid, secondKid)
+
+	// Reopen the same directory, simulating a process restart
+	reopened, err := NewKeySet(dir)
+	assert.NoError(t, err)
+
+	// The signing key must still be the one rotated in, not whatever
+	// os.ReadDir happens to return first
+	current, err := reopened.signingKeyFor()
+	assert.NoError(t, err)
+	assert.Equal(t, secondKid, current.kid)
+
+	// The retired key must still be verify-only, not eligible to sign again
+	reopened.mu.RLock()
+	retired := reopened.keys[firstKid.kid]
+	reopened.mu.RUnlock()
+	assert.True(t, retired.verifyOnly)
+	assert.False(t, retired.retireAt.IsZero())
+}
+
+func TestPruneDropsOnlyExpiredRetiredKeys(t *testing.T) {
+	dir := t.TempDir()
+	keySet, err := NewKeySet(dir)
+	assert.NoError(t, err)
+
+	firstKid, err := keySet.signingKeyFor()
+	assert.NoError(t, err)
+
+	// Retire the first key in the past, so Prune should drop it immediately
+	_, err = keySet.Rotate(-time.Minute)
+	assert.NoError(t, err)
+	secondKid, err := keySet.signingKeyFor()
+	assert.NoError(t, err)
+
+	// Retire the second key far in the future, so Prune must leave it alone
+	_, err = keySet.Rotate(time.Hour)
+	assert.NoError(t, err)
+
+	keySet.Prune()
+
+	keySet.mu.RLock()
+	_, firstStillPresent := keySet.keys[firstKid.kid]
+	_, secondStillPresent := keySet.keys[secondKid.kid]
+	keySet.mu.RUnlock()
+	assert.False(t, firstStillPresent)
+	assert.True(t, secondStillPresent)
+
+	// The dropped key's PEM file must be removed from disk too
+	_, err = os.Stat(filepath.Join(dir, firstKid.kid+".pem"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMakeAndValidateJWTWithES256KeySet(t *testing.T) {
+	dir := t.TempDir()
+	keySet, err := NewKeySet(dir, WithAlgorithm(AlgES256))
+	assert.NoError(t, err)
+
+	userID := uuid.New()
+	token, err := MakeJWTWithKeySet(userID, keySet, time.Minute)
+	assert.NoError(t, err)
+
+	parsedUserID, err := ValidateJWTWithKeySet(token, keySet)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, parsedUserID)
+
+	// The key's algorithm must also survive a reload from disk
+	reopened, err := NewKeySet(dir)
+	assert.NoError(t, err)
+	current, err := reopened.signingKeyFor()
+	assert.NoError(t, err)
+	assert.Equal(t, AlgES256, current.alg)
+}
+
+func TestJWKSPublishesBothKeyTypes(t *testing.T) {
+	rsaKeySet, err := NewKeySet(t.TempDir())
+	assert.NoError(t, err)
+	ecKeySet, err := NewKeySet(t.TempDir(), WithAlgorithm(AlgES256))
+	assert.NoError(t, err)
+
+	rsaKeys := rsaKeySet.JWKS()["keys"].([]map[string]interface{})
+	assert.Len(t, rsaKeys, 1)
+	assert.Equal(t, "RSA", rsaKeys[0]["kty"])
+	assert.Equal(t, "RS256", rsaKeys[0]["alg"])
+	assert.NotEmpty(t, rsaKeys[0]["n"])
+	assert.NotEmpty(t, rsaKeys[0]["e"])
+
+	ecKeys := ecKeySet.JWKS()["keys"].([]map[string]interface{})
+	assert.Len(t, ecKeys, 1)
+	assert.Equal(t, "EC", ecKeys[0]["kty"])
+	assert.Equal(t, "ES256", ecKeys[0]["alg"])
+	assert.Equal(t, "P-256", ecKeys[0]["crv"])
+	assert.NotEmpty(t, ecKeys[0]["x"])
+	assert.NotEmpty(t, ecKeys[0]["y"])
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// mtlsIdentityKey is the request context key under which ClientCAAuth stores
+// the authenticated client's identity.
+type mtlsIdentityKey struct{}
+
+// ClientIdentity is the subject of a verified client certificate.
+type ClientIdentity struct {
+	CommonName       string
+	OrganizationUnit string
+}
+
+// LoadCAPool reads a PEM-encoded CA bundle from path, for use as both the
+// TLS listener's ClientCAs pool and ClientCAAuth's trust anchor.
+func LoadCAPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificates found in CA bundle")
+	}
+	return pool, nil
+}
+
+// ClientCAAuth requires the request to carry a client certificate verified
+// against the TLS listener's configured CA pool, whose CN or OU appears in
+// allowedIdentities. On success it injects the identity into the request
+// context; on failure it responds 401 without calling next.
+func ClientCAAuth(allowedIdentities []string, next http.HandlerFunc) http.HandlerFunc {
+	allowed := make(map[string]bool, len(allowedIdentities))
+	for _, id := range allowedIdentities {
+		allowed[id] = true
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+
+		cert := r.TLS.PeerCertificates[0]
+		identity := ClientIdentity{CommonName: cert.Subject.CommonName}
+		if len(cert.Subject.OrganizationalUnit) > 0 {
+			identity.OrganizationUnit = cert.Subject.OrganizationalUnit[0]
+		}
+
+		if !allowed[identity.CommonName] && !allowed[identity.OrganizationUnit] {
+			http.Error(w, "client certificate not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), mtlsIdentityKey{}, identity)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// IdentityFromContext returns the client identity injected by ClientCAAuth.
+func IdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	identity, ok := ctx.Value(mtlsIdentityKey{}).(ClientIdentity)
+	return identity, ok
+}
+
+// MTLSListenerConfig builds a *tls.Config that requires and verifies client
+// certificates against caPool, for use by the admin TLS listener.
+func MTLSListenerConfig(caPool *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  caPool,
+	}
+}
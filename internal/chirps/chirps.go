@@ -0,0 +1,130 @@
+package chirps
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// MaxBodyLength is the longest a chirp's body is allowed to be.
+const MaxBodyLength = 140
+
+// DefaultBadWords is the deny list used when neither BAD_WORDS nor a bad
+// words file is configured.
+var DefaultBadWords = []string{"kerfuffle", "sharbert", "fornax"}
+
+// mask replaces a matched word, preserving nothing of the original.
+const mask = "****"
+
+// ErrBodyTooLong is returned by Sanitize when the cleaned body still
+// exceeds MaxBodyLength.
+var ErrBodyTooLong = errors.New("chirp exceeds the maximum length")
+
+// Sanitize lowercases-compares each whitespace-split word in body against
+// badWords and replaces matches with "****", preserving any leading or
+// trailing punctuation attached to the word, then enforces MaxBodyLength.
+// It returns an error if the cleaned body still exceeds the limit.
+func Sanitize(body string, badWords []string) (string, error) {
+	deny := make(map[string]bool, len(badWords))
+	for _, word := range badWords {
+		deny[strings.ToLower(word)] = true
+	}
+
+	words := strings.Split(body, " ")
+	for i, word := range words {
+		prefix, core, suffix := splitPunctuation(word)
+		if deny[strings.ToLower(core)] {
+			words[i] = prefix + mask + suffix
+		}
+	}
+	cleaned := strings.Join(words, " ")
+
+	if len(cleaned) > MaxBodyLength {
+		return "", fmt.Errorf("%w: got %d characters, limit is %d", ErrBodyTooLong, len(cleaned), MaxBodyLength)
+	}
+
+	return cleaned, nil
+}
+
+// splitPunctuation splits word into its leading punctuation, its core
+// letters/digits, and its trailing punctuation, so a matched core can be
+// masked without losing the punctuation around it (e.g. "kerfuffle!" ->
+// prefix "", core "kerfuffle", suffix "!").
+func splitPunctuation(word string) (prefix, core, suffix string) {
+	runes := []rune(word)
+
+	start := 0
+	for start < len(runes) && !isWordRune(runes[start]) {
+		start++
+	}
+
+	end := len(runes)
+	for end > start && !isWordRune(runes[end-1]) {
+		end--
+	}
+
+	return string(runes[:start]), string(runes[start:end]), string(runes[end:])
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// LoadBadWords resolves the deny list to use: the BAD_WORDS environment
+// variable (a comma-separated list) takes precedence, then a JSON file of
+// words at path (if non-empty), then DefaultBadWords.
+func LoadBadWords(env string, path string) ([]string, error) {
+	if env != "" {
+		var words []string
+		for _, word := range strings.Split(env, ",") {
+			if word = strings.TrimSpace(word); word != "" {
+				words = append(words, word)
+			}
+		}
+		return words, nil
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var words []string
+		if err := json.Unmarshal(data, &words); err != nil {
+			return nil, err
+		}
+		return words, nil
+	}
+
+	return DefaultBadWords, nil
+}
+
+// Sort directions accepted by BuildListQuery.
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// BuildListQuery builds the parameterised SQL (and its args) for listing
+// chirps, optionally filtered by authorID and ordered by created_at.
+// sortOrder defaults to SortAsc for any value other than SortDesc.
+func BuildListQuery(authorID string, sortOrder string) (string, []interface{}) {
+	query := "SELECT id, created_at, updated_at, body, user_id FROM chirps"
+	var args []interface{}
+
+	if authorID != "" {
+		args = append(args, authorID)
+		query += fmt.Sprintf(" WHERE user_id = $%d", len(args))
+	}
+
+	if sortOrder == SortDesc {
+		query += " ORDER BY created_at DESC"
+	} else {
+		query += " ORDER BY created_at ASC"
+	}
+
+	return query, args
+}
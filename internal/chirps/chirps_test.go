@@ -0,0 +1,105 @@
+package chirps
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	badWords := []string{"kerfuffle", "sharbert", "fornax"}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no bad words",
+			body: "This is a clean chirp",
+			want: "This is a clean chirp",
+		},
+		{
+			name: "single bad word",
+			body: "This is a kerfuffle opinion I need to share",
+			want: "This is a **** opinion I need to share",
+		},
+		{
+			name: "case insensitive match",
+			body: "Sharbert and FORNAX are funny words",
+			want: "**** and **** are funny words",
+		},
+		{
+			name: "masks a bad word while preserving trailing punctuation",
+			body: "kerfuffle! that's wild",
+			want: "****! that's wild",
+		},
+		{
+			name: "masks a bad word while preserving leading and trailing punctuation",
+			body: `"Sharbert," she said`,
+			want: `"****," she said`,
+		},
+		{
+			name:    "too long after cleaning",
+			body:    strings.Repeat("a", MaxBodyLength+1),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sanitize(tt.body, badWords)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadBadWordsFromEnv(t *testing.T) {
+	words, err := LoadBadWords("foo, bar ,baz", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo", "bar", "baz"}, words)
+}
+
+func TestLoadBadWordsDefault(t *testing.T) {
+	words, err := LoadBadWords("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBadWords, words)
+}
+
+func TestBuildListQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		authorID  string
+		sortOrder string
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "no filter, default sort",
+			sortOrder: "",
+			wantQuery: "SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC",
+		},
+		{
+			name:      "filtered by author, descending",
+			authorID:  "1234",
+			sortOrder: SortDesc,
+			wantQuery: "SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE user_id = $1 ORDER BY created_at DESC",
+			wantArgs:  []interface{}{"1234"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := BuildListQuery(tt.authorID, tt.sortOrder)
+			assert.Equal(t, tt.wantQuery, query)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
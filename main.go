@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"go-server/internal/auth"
+	"go-server/internal/chirps"
 	"net/http"
 	"os"
 	"strings"
@@ -16,13 +18,29 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// contextKey namespaces values stored on the request context so they don't
+// collide with keys set by other packages.
+type contextKey string
+
+const claimsUserIDKey contextKey = "claims_user_id"
+
 // Struct to hold stateful data
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *sql.DB
 	platform       string
+	jwksKeys       *auth.KeySet
+	polkaKey       string
+	badWords       []string
 }
 
+// Access tokens are short-lived; refresh tokens are long-lived and opaque,
+// stored in the refresh_tokens table so they can be looked up and revoked.
+const (
+	accessTokenExpiry  = time.Hour
+	refreshTokenExpiry = 60 * 24 * time.Hour
+)
+
 // Middleware to increment file server hits
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -31,6 +49,27 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
+// middlewareAuth validates the bearer JWT on the request and injects the
+// authenticated user's ID into the request context for handlers downstream.
+func (cfg *apiConfig) middlewareAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Missing or malformed authorization header")
+			return
+		}
+
+		userID, err := auth.ValidateJWTWithKeySet(token, cfg.jwksKeys)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsUserIDKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
 // Handler to return admin metrics
 func (cfg *apiConfig) adminMetricsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -60,10 +99,11 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 
 // Struct for User
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Email     string    `json:"email"`
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
 }
 
 // Handler to create a user
@@ -96,10 +136,10 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 	query := `
         INSERT INTO users (id, created_at, updated_at, email, hashed_password)
         VALUES ($1, $2, $3, $4, $5)
-        RETURNING id, created_at, updated_at, email`
+        RETURNING id, created_at, updated_at, email, is_chirpy_red`
 	err = cfg.db.QueryRowContext(r.Context(), query,
 		uuid.New(), time.Now(), time.Now(), requestBody.Email, hashedPassword,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Email)
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Email, &user.IsChirpyRed)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to create user")
 		return
@@ -109,6 +149,63 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 	respondWithJSON(w, http.StatusCreated, user)
 }
 
+// Combined handler for /api/users
+func (cfg *apiConfig) usersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		cfg.createUserHandler(w, r)
+	case http.MethodPut:
+		cfg.middlewareAuth(cfg.updateUserHandler)(w, r)
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// Handler to update the authenticated user's own email/password
+func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := r.Context().Value(claimsUserIDKey).(uuid.UUID)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Missing or malformed authorization header")
+		return
+	}
+
+	var requestBody struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil || requestBody.Email == "" || requestBody.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON or missing fields")
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(requestBody.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	var user User
+	err = cfg.db.QueryRowContext(r.Context(), `
+        UPDATE users
+        SET email = $1, hashed_password = $2, updated_at = $3
+        WHERE id = $4
+        RETURNING id, created_at, updated_at, email, is_chirpy_red`,
+		requestBody.Email, hashedPassword, time.Now(), userID,
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Email, &user.IsChirpyRed)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
 // Handler to reset all users
 func (cfg *apiConfig) resetUsersHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -116,8 +213,10 @@ func (cfg *apiConfig) resetUsersHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Check if the platform is "dev"
-	if cfg.platform != "dev" {
+	// Allow in dev, or when the caller presented a verified admin client
+	// certificate (injected by auth.ClientCAAuth on the mTLS listener)
+	_, hasClientIdentity := auth.IdentityFromContext(r.Context())
+	if cfg.platform != "dev" && !hasClientIdentity {
 		respondWithError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -141,20 +240,28 @@ func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	fmt.Println("POST /api/chirps handler invoked")
+
+	// The chirp's author is the authenticated user, not a client-supplied field
+	userID, ok := r.Context().Value(claimsUserIDKey).(uuid.UUID)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Missing or malformed authorization header")
+		return
+	}
+
 	// Parse the JSON body
 	var requestBody struct {
-		Body   string    `json:"body"`
-		UserID uuid.UUID `json:"user_id"`
+		Body string `json:"body"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&requestBody)
-	if err != nil || requestBody.Body == "" || requestBody.UserID == uuid.Nil {
+	if err != nil || requestBody.Body == "" {
 		respondWithError(w, http.StatusBadRequest, "Invalid JSON or missing fields")
 		return
 	}
 
-	// Validate the chirp length
-	if len(requestBody.Body) > 140 {
-		respondWithError(w, http.StatusBadRequest, "Chirp exceeds 140 characters")
+	// Clean profanity and enforce the length policy
+	cleanedBody, err := chirps.Sanitize(requestBody.Body, cfg.badWords)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -171,7 +278,7 @@ func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request)
     VALUES ($1, $2, $3, $4, $5)
     RETURNING id, created_at, updated_at, body, user_id`
 	err = cfg.db.QueryRowContext(r.Context(), query,
-		uuid.New(), time.Now(), time.Now(), requestBody.Body, requestBody.UserID,
+		uuid.New(), time.Now(), time.Now(), cleanedBody, userID,
 	).Scan(&chirp.ID, &chirp.CreatedAt, &chirp.UpdatedAt, &chirp.Body, &chirp.UserID)
 	if err != nil {
 		// Log the error for debugging
@@ -190,11 +297,9 @@ func (cfg *apiConfig) getAllChirpsHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Retrieve all chirps from the database
-	rows, err := cfg.db.QueryContext(r.Context(), `
-        SELECT id, created_at, updated_at, body, user_id
-        FROM chirps
-        ORDER BY created_at ASC`)
+	// Retrieve chirps from the database, optionally filtered by author and sorted
+	query, args := chirps.BuildListQuery(r.URL.Query().Get("author_id"), r.URL.Query().Get("sort"))
+	rows, err := cfg.db.QueryContext(r.Context(), query, args...)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve chirps")
 		return
@@ -202,7 +307,7 @@ func (cfg *apiConfig) getAllChirpsHandler(w http.ResponseWriter, r *http.Request
 	defer rows.Close()
 
 	// Parse the rows into a slice of chirps
-	var chirps []struct {
+	var chirpList []struct {
 		ID        uuid.UUID `json:"id"`
 		CreatedAt time.Time `json:"created_at"`
 		UpdatedAt time.Time `json:"updated_at"`
@@ -222,11 +327,11 @@ func (cfg *apiConfig) getAllChirpsHandler(w http.ResponseWriter, r *http.Request
 			respondWithError(w, http.StatusInternalServerError, "Failed to parse chirps")
 			return
 		}
-		chirps = append(chirps, chirp)
+		chirpList = append(chirpList, chirp)
 	}
 
 	// Respond with the chirps
-	respondWithJSON(w, http.StatusOK, chirps)
+	respondWithJSON(w, http.StatusOK, chirpList)
 }
 
 // Handler to get a single chirp by ID
@@ -277,7 +382,7 @@ func (cfg *apiConfig) getChirpByIDHandler(w http.ResponseWriter, r *http.Request
 func (cfg *apiConfig) chirpsHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
-		cfg.createChirpHandler(w, r) // Handle POST requests
+		cfg.middlewareAuth(cfg.createChirpHandler)(w, r) // Handle POST requests, JWT required
 	case http.MethodGet:
 		cfg.getAllChirpsHandler(w, r) // Handle GET requests
 	default:
@@ -285,6 +390,114 @@ func (cfg *apiConfig) chirpsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Handler to delete a chirp, only permitted for its author
+func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := r.Context().Value(claimsUserIDKey).(uuid.UUID)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Missing or malformed authorization header")
+		return
+	}
+
+	// Extract the chirpID from the URL path
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 4 || parts[3] == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+	chirpID := parts[3]
+
+	var authorID uuid.UUID
+	err := cfg.db.QueryRowContext(r.Context(), `
+        SELECT user_id FROM chirps WHERE id = $1`, chirpID,
+	).Scan(&authorID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve chirp")
+		return
+	}
+	if authorID != userID {
+		respondWithError(w, http.StatusForbidden, "You can only delete your own chirps")
+		return
+	}
+
+	_, err = cfg.db.ExecContext(r.Context(), "DELETE FROM chirps WHERE id = $1", chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete chirp")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for the Polka webhook that upgrades a user to Chirpy Red
+func (cfg *apiConfig) polkaWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || !auth.CheckAPIKey(apiKey, cfg.polkaKey) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing API key")
+		return
+	}
+
+	var requestBody struct {
+		Event string `json:"event"`
+		Data  struct {
+			UserID uuid.UUID `json:"user_id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if requestBody.Event != "user.upgraded" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	result, err := cfg.db.ExecContext(r.Context(), `
+        UPDATE users SET is_chirpy_red = true, updated_at = $1 WHERE id = $2`,
+		time.Now(), requestBody.Data.UserID,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to upgrade user")
+		return
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to upgrade user")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Combined handler for /api/chirps/{chirpID}
+func (cfg *apiConfig) chirpByIDHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg.getChirpByIDHandler(w, r)
+	case http.MethodDelete:
+		cfg.middlewareAuth(cfg.deleteChirpHandler)(w, r)
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
 func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -308,14 +521,15 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		CreatedAt      time.Time `json:"created_at"`
 		UpdatedAt      time.Time `json:"updated_at"`
 		Email          string    `json:"email"`
+		IsChirpyRed    bool      `json:"is_chirpy_red"`
 		HashedPassword string    `json:"-"`
 	}
 	query := `
-        SELECT id, created_at, updated_at, email, hashed_password
+        SELECT id, created_at, updated_at, email, is_chirpy_red, hashed_password
         FROM users
         WHERE email = $1`
 	err = cfg.db.QueryRowContext(r.Context(), query, requestBody.Email).Scan(
-		&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Email, &user.HashedPassword,
+		&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Email, &user.IsChirpyRed, &user.HashedPassword,
 	)
 	if err == sql.ErrNoRows {
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
@@ -332,20 +546,136 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Respond with the user (excluding the password)
+	// Mint a short-lived access token
+	token, err := auth.MakeJWTWithKeySet(user.ID, cfg.jwksKeys, accessTokenExpiry)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+
+	// Mint a long-lived refresh token and persist it
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create refresh token")
+		return
+	}
+	_, err = cfg.db.ExecContext(r.Context(), `
+        INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+        VALUES ($1, $2, $3, $4, $5, NULL)`,
+		refreshToken, time.Now(), time.Now(), user.ID, time.Now().Add(refreshTokenExpiry),
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to store refresh token")
+		return
+	}
+
+	// Respond with the user (excluding the password) and both tokens
 	respondWithJSON(w, http.StatusOK, struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Email     string    `json:"email"`
+		ID           uuid.UUID `json:"id"`
+		CreatedAt    time.Time `json:"created_at"`
+		UpdatedAt    time.Time `json:"updated_at"`
+		Email        string    `json:"email"`
+		IsChirpyRed  bool      `json:"is_chirpy_red"`
+		Token        string    `json:"token"`
+		RefreshToken string    `json:"refresh_token"`
 	}{
-		ID:        user.ID,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		Email:     user.Email,
+		ID:           user.ID,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+		Email:        user.Email,
+		IsChirpyRed:  user.IsChirpyRed,
+		Token:        token,
+		RefreshToken: refreshToken,
 	})
 }
 
+// Handler to mint a fresh access token from a valid refresh token
+func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	refreshToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or malformed authorization header")
+		return
+	}
+
+	// Look up the refresh token and make sure it's still usable
+	var userID uuid.UUID
+	var expiresAt time.Time
+	var revokedAt sql.NullTime
+	err = cfg.db.QueryRowContext(r.Context(), `
+        SELECT user_id, expires_at, revoked_at
+        FROM refresh_tokens
+        WHERE token = $1`, refreshToken,
+	).Scan(&userID, &expiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to look up refresh token")
+		return
+	}
+	if revokedAt.Valid || time.Now().After(expiresAt) {
+		respondWithError(w, http.StatusUnauthorized, "Refresh token expired or revoked")
+		return
+	}
+
+	// Look up the user's current Chirpy Red status so it stays current
+	// even if it changed since the refresh token was issued
+	var isChirpyRed bool
+	err = cfg.db.QueryRowContext(r.Context(), `
+        SELECT is_chirpy_red FROM users WHERE id = $1`, userID,
+	).Scan(&isChirpyRed)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve user")
+		return
+	}
+
+	// Mint a fresh access token bound to the same user
+	token, err := auth.MakeJWTWithKeySet(userID, cfg.jwksKeys, accessTokenExpiry)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Token       string `json:"token"`
+		IsChirpyRed bool   `json:"is_chirpy_red"`
+	}{
+		Token:       token,
+		IsChirpyRed: isChirpyRed,
+	})
+}
+
+// Handler to revoke a refresh token
+func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	refreshToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or malformed authorization header")
+		return
+	}
+
+	_, err = cfg.db.ExecContext(r.Context(), `
+        UPDATE refresh_tokens
+        SET revoked_at = $1, updated_at = $1
+        WHERE token = $2`, time.Now(), refreshToken,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke refresh token")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func main() {
 	// Load environment variables from .env file
 	godotenv.Load()
@@ -353,6 +683,40 @@ func main() {
 	// Read the PLATFORM environment variable
 	platform := os.Getenv("PLATFORM")
 
+	// Read the POLKA_KEY environment variable used to authenticate webhooks
+	polkaKey := os.Getenv("POLKA_KEY")
+
+	// Load the profanity deny list used to sanitize chirp bodies
+	badWords, err := chirps.LoadBadWords(os.Getenv("BAD_WORDS"), os.Getenv("BAD_WORDS_FILE"))
+	if err != nil {
+		panic(err)
+	}
+
+	// Load (or generate, on first boot) the RS256/ES256 keys used to sign
+	// the JWKS-published tokens
+	keysDir := os.Getenv("JWKS_KEY_DIR")
+	if keysDir == "" {
+		keysDir = "./keys"
+	}
+	var keySetOpts []auth.KeySetOption
+	if os.Getenv("JWKS_KEY_ALG") == "ES256" {
+		keySetOpts = append(keySetOpts, auth.WithAlgorithm(auth.AlgES256))
+	}
+	jwksKeys, err := auth.NewKeySet(keysDir, keySetOpts...)
+	if err != nil {
+		panic(err)
+	}
+
+	// A "rotate-keys" subcommand rotates the active signing key without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		kid, err := jwksKeys.Rotate(accessTokenExpiry)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println("rotated signing key, new kid:", kid)
+		return
+	}
+
 	// Connect to the database
 	dbURL := os.Getenv("DB_URL")
 	db, err := sql.Open("postgres", dbURL)
@@ -367,7 +731,20 @@ func main() {
 	apiCfg := &apiConfig{
 		db:       db,
 		platform: platform,
-	}
+		jwksKeys: jwksKeys,
+		polkaKey: polkaKey,
+		badWords: badWords,
+	}
+
+	// Periodically drop retired signing keys once no outstanding token can
+	// still reference them, so rotated-out keys don't accumulate forever.
+	go func() {
+		ticker := time.NewTicker(accessTokenExpiry)
+		defer ticker.Stop()
+		for range ticker.C {
+			jwksKeys.Prune()
+		}
+	}()
 
 	// Add the readiness endpoint (GET only) under /api
 	mux.HandleFunc("/api/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -382,17 +759,31 @@ func main() {
 	fileServer := http.FileServer(http.Dir("."))
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", fileServer)))
 
-	// Add the admin metrics endpoint (GET only) under /admin
+	// Add the admin metrics endpoint (GET only) under /admin. In dev this is
+	// gated only by PLATFORM=dev; when ADMIN_CA_FILE is set, the same
+	// handlers are also served over mTLS below with a real cryptographic gate.
 	mux.HandleFunc("/admin/metrics", apiCfg.adminMetricsHandler)
 
 	// Add the admin reset endpoint (POST only) under /admin
 	mux.HandleFunc("/admin/reset", apiCfg.resetUsersHandler)
 
 	// Add the create user endpoint (POST only) under /api
-	mux.HandleFunc("/api/users", apiCfg.createUserHandler)
+	mux.HandleFunc("/api/users", apiCfg.usersHandler)
 	mux.HandleFunc("/api/chirps", apiCfg.chirpsHandler)
-	mux.HandleFunc("/api/chirps/", apiCfg.getChirpByIDHandler)
+	mux.HandleFunc("/api/chirps/", apiCfg.chirpByIDHandler)
 	mux.HandleFunc("/api/login", apiCfg.loginHandler)
+	mux.HandleFunc("/api/polka/webhooks", apiCfg.polkaWebhookHandler)
+	mux.HandleFunc("/api/refresh", apiCfg.refreshHandler)
+	mux.HandleFunc("/api/revoke", apiCfg.revokeHandler)
+
+	// Publish the current public keys so other services can validate our JWTs
+	mux.HandleFunc("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, apiCfg.jwksKeys.JWKS())
+	})
 
 	// Create a new HTTP server
 	server := &http.Server{
@@ -400,6 +791,38 @@ func main() {
 		Handler: mux,
 	}
 
+	// If an admin CA bundle is configured, also serve the admin endpoints
+	// over mTLS, requiring a client certificate signed by that CA.
+	if adminCAFile := os.Getenv("ADMIN_CA_FILE"); adminCAFile != "" {
+		caPool, err := auth.LoadCAPool(adminCAFile)
+		if err != nil {
+			panic(err)
+		}
+
+		var allowedIdentities []string
+		for _, id := range strings.Split(os.Getenv("ADMIN_ALLOWED_IDENTITIES"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				allowedIdentities = append(allowedIdentities, id)
+			}
+		}
+
+		adminMux := http.NewServeMux()
+		adminMux.HandleFunc("/admin/metrics", auth.ClientCAAuth(allowedIdentities, apiCfg.adminMetricsHandler))
+		adminMux.HandleFunc("/admin/reset", auth.ClientCAAuth(allowedIdentities, apiCfg.resetUsersHandler))
+
+		adminServer := &http.Server{
+			Addr:      ":8443",
+			Handler:   adminMux,
+			TLSConfig: auth.MTLSListenerConfig(caPool),
+		}
+		go func() {
+			err := adminServer.ListenAndServeTLS(os.Getenv("ADMIN_CERT_FILE"), os.Getenv("ADMIN_KEY_FILE"))
+			if err != nil && err != http.ErrServerClosed {
+				panic(err)
+			}
+		}()
+	}
+
 	// Start the server
 	err = server.ListenAndServe()
 	if err != nil {
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"go-server/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareAuthInjectsAuthenticatedUserID(t *testing.T) {
+	keySet, err := auth.NewKeySet(t.TempDir())
+	assert.NoError(t, err)
+	cfg := &apiConfig{jwksKeys: keySet}
+
+	userID := uuid.New()
+	token, err := auth.MakeJWTWithKeySet(userID, keySet, time.Minute)
+	assert.NoError(t, err)
+
+	var gotUserID uuid.UUID
+	handler := cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request) {
+		gotUserID, _ = r.Context().Value(claimsUserIDKey).(uuid.UUID)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	handler(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, userID, gotUserID)
+}
+
+func TestMiddlewareAuthRejectsMissingAuthorizationHeader(t *testing.T) {
+	keySet, err := auth.NewKeySet(t.TempDir())
+	assert.NoError(t, err)
+	cfg := &apiConfig{jwksKeys: keySet}
+
+	handler := cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not be called")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps", nil)
+	w := httptest.NewRecorder()
+
+	handler(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMiddlewareAuthRejectsInvalidToken(t *testing.T) {
+	keySet, err := auth.NewKeySet(t.TempDir())
+	assert.NoError(t, err)
+	cfg := &apiConfig{jwksKeys: keySet}
+
+	handler := cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not be called")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps", nil)
+	req.Header.Set("Authorization", "Bearer not-a-real-token")
+	w := httptest.NewRecorder()
+
+	handler(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}